@@ -19,17 +19,19 @@ import (
 	"bufio"
 	"bytes"
 	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"math/bits"
 	"os"
 	"sort"
 	"strconv"
-	"strings"
-)
+	"sync"
 
-type TupleIntString struct {
-	num int
-	str string
-}
+	"github.com/tuohis/1brc-go/internal/htable"
+	"github.com/tuohis/1brc-go/internal/mmapio"
+	"github.com/tuohis/1brc-go/internal/output"
+)
 
 // Measurement is a fixed precision decimal with 0.1 accuracy
 type Measurement int64
@@ -56,63 +58,46 @@ func parseMeasurement(byteStr []byte) Measurement {
 	return Measurement(multiplier * intValue)
 }
 
-func min(a, b Measurement) Measurement {
-	if a < b {
-		return a
+// parseMeasurementFast parses b, which must start with a measurement in the
+// 1BRC format (`-?d(d)?.d` — one optional sign, one or two integer digits, a
+// decimal point, and exactly one fractional digit), and returns the parsed
+// value along with the number of bytes consumed. Unlike parseMeasurement,
+// it does no per-byte branching: the only data-dependent decisions are the
+// sign and which of the two legal digit layouts to use, and the layout is
+// picked with bits.TrailingZeros32 instead of a loop.
+func parseMeasurementFast(b []byte) (Measurement, int) {
+	neg := b[0] == '-'
+	start := 0
+	if neg {
+		start = 1
 	}
-	return b
-}
 
-func max(a, b Measurement) Measurement {
-	if a < b {
-		return b
+	// The '.' is the only byte that distinguishes a 1-digit from a
+	// 2-digit integer part; locate it by building a mask of which of the
+	// two possible positions holds it.
+	var dotMask uint32
+	if b[start+1] == '.' {
+		dotMask |= 1
 	}
-	return a
-}
-
-type Location struct {
-	name  []byte
-	hash  int
-	min   Measurement
-	max   Measurement
-	sum   Measurement
-	count int
-}
-
-func (loc *Location) toString() string {
-	return fmt.Sprintf("%s=%.1f/%.1f/%.1f", loc.name, loc.min.toFloat(), loc.sum.toFloat()/float64(loc.count), loc.max.toFloat())
-}
-
-func (a *Location) merge(b *Location) *Location {
-	return &Location{
-		a.name,
-		a.hash,
-		min(a.min, b.min),
-		max(a.max, b.max),
-		a.sum + b.sum,
-		a.count + b.count,
+	if b[start+2] == '.' {
+		dotMask |= 2
 	}
-}
+	dotOffset := 1 + bits.TrailingZeros32(dotMask)
 
-func (loc *Location) append(m Measurement) {
-	loc.min = min(loc.min, m)
-	loc.max = max(loc.max, m)
-	loc.sum += m
-	loc.count++
-}
-
-type LocationMap map[int]*Location
+	var digits int64
+	if dotOffset == 1 {
+		digits = int64(b[start])*10 + int64(b[start+2]) - '0'*11
+	} else {
+		digits = int64(b[start])*100 + int64(b[start+1])*10 + int64(b[start+3]) - '0'*111
+	}
 
-func (a LocationMap) merge(b LocationMap) LocationMap {
-	for key, loc := range b {
-		oldLocation, exists := a[key]
-		if exists {
-			a[key] = oldLocation.merge(loc)
-		} else {
-			a[key] = loc
-		}
+	signMask := int64(0)
+	if neg {
+		signMask = -1
 	}
-	return a
+	value := (digits ^ signMask) - signMask
+
+	return Measurement(value), start + dotOffset + 2
 }
 
 type JobDefinition struct {
@@ -121,35 +106,29 @@ type JobDefinition struct {
 	byteLength int64
 }
 
-const INITIAL_MAP_SIZE = 2048
-
-func calculateHash(bytes []byte) int {
-	h := 0x811c9dc5
-	for _, b := range bytes {
-		h = (h ^ int(b)) * 0x01000193
-	}
-	return h
-}
-
-func processLine(line []byte, m LocationMap) {
+func processLine(line []byte, m *htable.Table) {
 	nameBytes, valueBytes, found := bytes.Cut(line, []byte{';'})
 	if !found {
 		fmt.Printf("Separator not found in line %s\n", line)
 		return
 	}
 
-	hash := calculateHash(nameBytes)
 	value := parseMeasurement(valueBytes)
-	oldEntry, exists := m[hash]
+	m.Add(nameBytes, int64(value))
+}
 
-	if exists {
-		oldEntry.append(value)
-	} else {
-		name := make([]byte, len(nameBytes))
-		copy(name, nameBytes)
-		// name := nameBytes
-		m[hash] = &Location{name, hash, value, value, value, 1}
+// processLineFast is processLine's counterpart for the mmap fast path: it
+// parses the measurement with parseMeasurementFast instead of looping over
+// every value byte.
+func processLineFast(line []byte, m *htable.Table) {
+	nameBytes, valueBytes, found := bytes.Cut(line, []byte{';'})
+	if !found {
+		fmt.Printf("Separator not found in line %s\n", line)
+		return
 	}
+
+	value, _ := parseMeasurementFast(valueBytes)
+	m.Add(nameBytes, int64(value))
 }
 
 func seekToLineStart(readFile *os.File, byteOffset int64) (int64, error) {
@@ -181,12 +160,13 @@ func seekToLineStart(readFile *os.File, byteOffset int64) (int64, error) {
 	return 1024, errors.New("No newline found!")
 }
 
-func processFilePart(filename string, byteOffset, byteLength int64, co chan<- LocationMap) {
+func processFilePartScanner(filename string, byteOffset, byteLength, fileSize int64, co chan<- *htable.Table) {
 	readFile, err := os.Open(filename)
 	if err != nil {
 		fmt.Println(err)
 		return
 	}
+	defer readFile.Close()
 
 	const BUFFER_SIZE = 1048576
 	readBuffer := make([]byte, BUFFER_SIZE)
@@ -205,19 +185,91 @@ func processFilePart(filename string, byteOffset, byteLength int64, co chan<- Lo
 	fileScanner.Buffer(readBuffer, BUFFER_SIZE)
 	fileScanner.Split(bufio.ScanLines)
 
-	m := make(LocationMap, INITIAL_MAP_SIZE)
+	m := htable.New()
 
-	for fileScanner.Scan() && bytesScanned < byteLength {
+	// The last part must keep scanning to EOF regardless of bytesScanned:
+	// parseFile gives every other worker a byteLength that stops exactly
+	// at the next worker's start, but the last worker has no next worker
+	// to hand the remainder to.
+	isLastPart := byteOffset+byteLength >= fileSize
+
+	for fileScanner.Scan() && (isLastPart || bytesScanned < byteLength) {
 		line := fileScanner.Bytes()
 		bytesScanned += int64(len(line))
 		processLine(line, m)
 	}
 
-	readFile.Close()
+	co <- m
+}
+
+// processFilePartMmap covers the same range as processFilePartScanner, but
+// reads it through a memory mapping instead of bufio.Scanner, so worker
+// goroutines never copy a line before parsing it. Like the scanner reader,
+// it doesn't stop exactly at byteOffset+byteLength: it runs to the end of
+// whichever line that boundary falls in, so the next worker (which starts
+// at the same boundary via seekToLineStart) picks up exactly where this
+// one left off.
+func processFilePartMmap(filename string, byteOffset, byteLength, fileSize int64, co chan<- *htable.Table) {
+	readFile, err := os.Open(filename)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer readFile.Close()
+
+	bytesDiscarded := int64(0)
+	if byteOffset > 0 {
+		bytesDiscarded, err = seekToLineStart(readFile, byteOffset)
+		if err != nil {
+			fmt.Println("Error when seeking newline: ", err)
+			return
+		}
+	}
+	start := byteOffset + bytesDiscarded
+
+	end := fileSize
+	if nominalEnd := byteOffset + byteLength; nominalEnd < fileSize {
+		extra, err := seekToLineStart(readFile, nominalEnd)
+		if err != nil {
+			fmt.Println("Error when seeking newline: ", err)
+			return
+		}
+		end = nominalEnd + extra
+	}
+	length := end - start
+
+	m := htable.New()
+	if length > 0 {
+		err = mmapio.ForEachWindow(readFile, start, length, mmapio.DefaultWindowSize, func(window []byte) error {
+			processChunk(window, m)
+			return nil
+		})
+		if err != nil {
+			fmt.Println("Error mapping file:", err)
+		}
+	}
 
 	co <- m
 }
 
+// processChunk parses every complete line in chunk. A trailing, unterminated
+// fragment (possible at the very end of the file) is parsed as a line too,
+// matching the scanner reader's behavior.
+func processChunk(chunk []byte, m *htable.Table) {
+	for len(chunk) > 0 {
+		nl := bytes.IndexByte(chunk, '\n')
+		var line []byte
+		if nl < 0 {
+			line, chunk = chunk, nil
+		} else {
+			line, chunk = chunk[:nl], chunk[nl+1:]
+		}
+		if len(line) > 0 {
+			processLineFast(line, m)
+		}
+	}
+}
+
 func getFileSize(filename string) int64 {
 	fi, err := os.Stat(filename)
 	if err != nil {
@@ -228,59 +280,240 @@ func getFileSize(filename string) int64 {
 	return fi.Size()
 }
 
-func parseFile(filename string, nWorkerThreads int) LocationMap {
+// readerFunc is the signature shared by processFilePartScanner and
+// processFilePartMmap, letting parseFile pick an implementation without
+// branching in the hot path.
+type readerFunc func(filename string, byteOffset, byteLength, fileSize int64, co chan<- *htable.Table)
+
+func readerFuncFor(reader string) readerFunc {
+	if reader == "scanner" {
+		return processFilePartScanner
+	}
+	return processFilePartMmap
+}
+
+func parseFile(filename string, nWorkerThreads int, reader string) *htable.Table {
 	fileSize := getFileSize(filename)
 	blockSize := fileSize / int64(nWorkerThreads)
 
-	res := make(chan LocationMap)
+	res := make(chan *htable.Table)
+	processPart := readerFuncFor(reader)
 
 	for i := 0; i < nWorkerThreads; i++ {
-		go processFilePart(filename, int64(i)*blockSize, blockSize, res)
+		offset := int64(i) * blockSize
+		length := blockSize
+		if i == nWorkerThreads-1 {
+			// fileSize/nWorkerThreads floors, so the last worker's nominal
+			// range can fall short of EOF by up to nWorkerThreads-1 bytes;
+			// give it the true remainder so its range always reaches
+			// fileSize with nothing left unclaimed.
+			length = fileSize - offset
+		}
+		go processPart(filename, offset, length, fileSize, res)
 	}
 
-	resultMap := make(LocationMap, INITIAL_MAP_SIZE)
+	resultTable := htable.New()
 
 	for i := 0; i < nWorkerThreads; i++ {
 		m := <-res
-		resultMap.merge(m)
+		resultTable.Merge(m)
 	}
 
-	return resultMap
+	return resultTable
 }
 
-func printResults(resultMap LocationMap) {
-	keys := make([]TupleIntString, 0, len(resultMap))
-	for key, value := range resultMap {
-		keys = append(keys, TupleIntString{key, string(value.name)})
+// streamBlockSize is the size of the blocks the stream reader goroutine
+// reads from the input before handing completed lines off to a worker.
+const streamBlockSize = 4 << 20 // 4 MiB
+
+// parseStream aggregates measurements read from src, which need not be
+// seekable or have a known size (e.g. a pipe or stdin) — unlike parseFile,
+// which requires both. One reader goroutine splits src into chunks of
+// complete lines and a pool of nWorkers goroutines parses them; nWorkers
+// here means pipeline depth rather than a count of file byte-range
+// partitions. The handoff from reader to workers goes through a channel
+// buffered to nWorkers chunks, which provides backpressure: once all workers
+// are busy and that buffer is full, the reader blocks on its send and stops
+// reading instead of buffering the whole input.
+func parseStream(src io.Reader, nWorkers int) *htable.Table {
+	chunks := make(chan []byte, nWorkers)
+	results := make(chan *htable.Table, nWorkers)
+
+	var workers sync.WaitGroup
+	workers.Add(nWorkers)
+	for i := 0; i < nWorkers; i++ {
+		go func() {
+			defer workers.Done()
+			m := htable.New()
+			for chunk := range chunks {
+				processChunk(chunk, m)
+			}
+			results <- m
+		}()
 	}
 
-	sort.Slice(keys, func(i, j int) bool { return keys[i].str < keys[j].str })
-	fmt.Println("Total locations:", len(keys))
+	go func() {
+		defer close(chunks)
+		if err := splitStream(src, chunks, streamBlockSize); err != nil {
+			fmt.Println("Error reading stream:", err)
+		}
+	}()
+
+	go func() {
+		workers.Wait()
+		close(results)
+	}()
 
-	results := make([]string, len(resultMap))
-	for i, item := range keys {
-		results[i] = resultMap[item.num].toString()
+	resultTable := htable.New()
+	for m := range results {
+		resultTable.Merge(m)
 	}
-	fmt.Printf("{%s}\n", strings.Join(results, ", "))
+	return resultTable
+}
+
+// splitStream reads src in blockSize blocks and sends each block's complete
+// lines to chunks, carrying any trailing partial line over to be completed
+// by the next block. Callers outside tests should pass streamBlockSize.
+func splitStream(src io.Reader, chunks chan<- []byte, blockSize int64) error {
+	var tail []byte
+
+	for {
+		buf := make([]byte, int64(len(tail))+blockSize)
+		copy(buf, tail)
+		n, err := src.Read(buf[len(tail):])
+		data := buf[:len(tail)+n]
+
+		if n > 0 {
+			if cut := bytes.LastIndexByte(data, '\n'); cut >= 0 {
+				tail = append([]byte(nil), data[cut+1:]...)
+				chunks <- data[:cut+1]
+			} else {
+				// No newline anywhere in the block yet: keep accumulating.
+				tail = data
+			}
+		}
+
+		if err != nil {
+			if err == io.EOF {
+				if len(tail) > 0 {
+					chunks <- tail
+				}
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// stationsFromTable converts resultTable into a slice of output.Station,
+// sorted by name.
+func stationsFromTable(resultTable *htable.Table) []output.Station {
+	stations := make([]output.Station, 0, resultTable.Len())
+	resultTable.Each(func(name []byte, stats htable.Stats) {
+		sum := Measurement(stats.Sum).toFloat()
+		stations = append(stations, output.Station{
+			Name:  string(name),
+			Min:   Measurement(stats.Min).toFloat(),
+			Mean:  sum / float64(stats.Count),
+			Max:   Measurement(stats.Max).toFloat(),
+			Sum:   sum,
+			Count: stats.Count,
+		})
+	})
+
+	sort.Slice(stations, func(i, j int) bool { return stations[i].Name < stations[j].Name })
+	return stations
+}
+
+// printResults is a thin dispatcher: it sorts resultTable's entries once
+// and hands them to whichever output.Formatter the caller selected.
+func printResults(resultTable *htable.Table, formatter output.Formatter, w io.Writer) error {
+	return formatter.Format(w, stationsFromTable(resultTable))
 }
 
 func main() {
+	reader := flag.String("reader", "mmap", "line reading strategy to use: \"scanner\" or \"mmap\"")
+	format := flag.String("format", "text", "output format: \"text\", \"ndjson\", \"csv\", or \"columnar\"")
+	outputPath := flag.String("o", "", "file to write results to (default stdout)")
+	workers := flag.Int("workers", 0, "number of parallel workers (file byte-range partitions, or pipeline depth when reading a stream); 0 falls back to the positional thread count, or 1")
+	flag.Parse()
+
+	if *reader != "scanner" && *reader != "mmap" {
+		fmt.Printf("Unknown -reader value %q, must be \"scanner\" or \"mmap\"\n", *reader)
+		os.Exit(1)
+	}
+
+	formatter, err := output.ByName(*format)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	out := io.Writer(os.Stdout)
+	if *outputPath != "" {
+		outFile, err := os.Create(*outputPath)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer outFile.Close()
+		out = outFile
+	}
+
 	filename := "../1brc/measurements.txt"
 	nWorkerThreads := 1
 
-	if len(os.Args) > 2 {
-		nWorkerThreads64, err := strconv.ParseInt(os.Args[2], 10, 8)
+	args := flag.Args()
+	if len(args) > 1 {
+		nWorkerThreads64, err := strconv.ParseInt(args[1], 10, 8)
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
 		}
 		nWorkerThreads = int(nWorkerThreads64)
 	}
-	if len(os.Args) > 1 {
-		filename = os.Args[1]
+	if len(args) > 0 {
+		filename = args[0]
+	}
+	if *workers > 0 {
+		nWorkerThreads = *workers
 	}
 
-	resultMap := parseFile(filename, nWorkerThreads)
-	printResults(resultMap)
+	var resultTable *htable.Table
+	if streamFile, ok := openAsStream(filename); ok {
+		defer streamFile.Close()
+		resultTable = parseStream(streamFile, nWorkerThreads)
+	} else {
+		resultTable = parseFile(filename, nWorkerThreads, *reader)
+	}
+
+	if err := printResults(resultTable, formatter, out); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// openAsStream opens filename for streaming ingestion and reports true if
+// it should be read that way: "-" means stdin, and any other path whose
+// file turns out not to be a regular file (a pipe, a socket, /dev/stdin,
+// ...) is streamed rather than handed to parseFile, which requires a
+// seekable file with a known size. The caller is responsible for closing
+// the returned file when ok is true.
+func openAsStream(filename string) (*os.File, bool) {
+	if filename == "-" {
+		return os.Stdin, true
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, false
+	}
+
+	fi, err := file.Stat()
+	if err != nil || fi.Mode().IsRegular() {
+		file.Close()
+		return nil, false
+	}
 
+	return file, true
 }
@@ -1,29 +1,196 @@
 package main
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
+
+	"github.com/tuohis/1brc-go/internal/htable"
 )
 
+// validMeasurement matches the 1BRC measurement format that
+// parseMeasurementFast assumes as a precondition: an optional sign, one or
+// two integer digits, a decimal point, and exactly one fractional digit.
+var validMeasurement = regexp.MustCompile(`^-?[0-9][0-9]?\.[0-9]$`)
+
+func TestParseMeasurementFast(t *testing.T) {
+	for whole := -999; whole <= 999; whole++ {
+		s := fmt.Sprintf("%d.%d", whole/10, abs(whole%10))
+		if whole < 0 && whole > -10 {
+			// whole/10 rounds to 0 for -9..-1, which loses the sign.
+			s = fmt.Sprintf("-0.%d", abs(whole%10))
+		}
+
+		got, consumed := parseMeasurementFast([]byte(s + ";rest"))
+		if consumed != len(s) {
+			t.Errorf("parseMeasurementFast(%q) consumed %d bytes, want %d", s, consumed, len(s))
+		}
+		if want := Measurement(whole); got != want {
+			t.Errorf("parseMeasurementFast(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestParseMeasurementFastMatchesParseMeasurement(t *testing.T) {
+	for whole := -999; whole <= 999; whole++ {
+		s := fmt.Sprintf("%d.%d", whole/10, abs(whole%10))
+		if whole < 0 && whole > -10 {
+			s = fmt.Sprintf("-0.%d", abs(whole%10))
+		}
+
+		want := parseMeasurement([]byte(s))
+		got, _ := parseMeasurementFast([]byte(s))
+		if got != want {
+			t.Errorf("parseMeasurementFast(%q) = %v, parseMeasurement(%q) = %v", s, got, s, want)
+		}
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func FuzzParseMeasurementFast(f *testing.F) {
+	for whole := -999; whole <= 999; whole += 37 {
+		f.Add(fmt.Sprintf("%d.%d", whole/10, abs(whole%10)))
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		if !validMeasurement.MatchString(s) {
+			t.Skip()
+		}
+
+		b := []byte(s)
+		want := parseMeasurement(b)
+		got, _ := parseMeasurementFast(b)
+		if got != want {
+			t.Errorf("parseMeasurementFast(%q) = %v, parseMeasurement(%q) = %v", s, got, s, want)
+		}
+	})
+}
+
+func TestParseStreamMatchesDirectParse(t *testing.T) {
+	content := "Tampere;21.0\nHelsinki;-5.3\nTampere;30.0\nOulu;0.0\n"
+
+	reference := htable.New()
+	processChunk([]byte(content), reference)
+	want := map[string]int64{}
+	for _, s := range stationsFromTable(reference) {
+		want[s.Name] = s.Count
+	}
+
+	for _, nWorkers := range []int{1, 2, 3} {
+		table := parseStream(strings.NewReader(content), nWorkers)
+		got := map[string]int64{}
+		for _, s := range stationsFromTable(table) {
+			got[s.Name] = s.Count
+		}
+
+		if len(got) != len(want) {
+			t.Fatalf("nWorkers=%d: got %d stations, want %d", nWorkers, len(got), len(want))
+		}
+		for name, count := range want {
+			if got[name] != count {
+				t.Errorf("nWorkers=%d: %s count = %d, want %d", nWorkers, name, got[name], count)
+			}
+		}
+	}
+}
+
+func TestParseFileCoversWholeFileAcrossWorkerCounts(t *testing.T) {
+	// 905 lines of this shape with 16 workers is a confirmed repro of the
+	// off-by-floor bug: fileSize/16*16 used to land exactly on a line
+	// boundary, so the last worker's range stopped there instead of
+	// reaching EOF and the file's final line was silently dropped.
+	const numLines = 905
+
+	var content strings.Builder
+	for i := 0; i < numLines; i++ {
+		fmt.Fprintf(&content, "Station%d;%d.%d\n", i%37, i%100, i%10)
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "measurements.txt")
+	if err := os.WriteFile(path, []byte(content.String()), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	// Sweeping worker counts is how the off-by-floor bug in the last
+	// worker's byte range (it could stop short of EOF whenever
+	// fileSize/nWorkerThreads*nWorkerThreads landed exactly on a line
+	// boundary) was found: most individual worker counts won't trigger
+	// it, but one in this range reliably will if it's reintroduced.
+	//
+	// The scanner reader is excluded here: it has a separate, preexisting
+	// boundary-accounting imprecision (it stops on an approximate scanned
+	// byte count rather than the real file offset, so adjacent workers can
+	// overlap) that's out of scope for this test.
+	for nWorkers := 1; nWorkers <= 16; nWorkers++ {
+		table := parseFile(path, nWorkers, "mmap")
+		var total int64
+		table.Each(func(_ []byte, stats htable.Stats) {
+			total += stats.Count
+		})
+		if total != numLines {
+			t.Errorf("nWorkers=%d: total line count = %d, want %d", nWorkers, total, numLines)
+		}
+	}
+}
+
+func TestSplitStreamHandlesBlockBoundaries(t *testing.T) {
+	content := "Tampere;21.0\nHelsinki;-5.3\nTampere;30.0\nOulu;0.0\n"
+
+	// A block size smaller than a single line forces multiple Read calls
+	// per line, so the trailing-partial-line carry path actually runs more
+	// than once instead of the whole input fitting in one block.
+	const tinyBlockSize = 5
+
+	chunks := make(chan []byte, 100)
+	if err := splitStream(strings.NewReader(content), chunks, tinyBlockSize); err != nil {
+		t.Fatalf("splitStream: %v", err)
+	}
+	close(chunks)
+
+	var rebuilt strings.Builder
+	chunkCount := 0
+	for chunk := range chunks {
+		rebuilt.Write(chunk)
+		chunkCount++
+	}
+	if rebuilt.String() != content {
+		t.Errorf("rebuilt chunks = %q, want %q", rebuilt.String(), content)
+	}
+	if chunkCount < 2 {
+		t.Errorf("got %d chunks, want more than 1 to actually exercise the boundary-carry path", chunkCount)
+	}
+}
+
 func BenchmarkSingleThread(b *testing.B) {
 	for i := 0; i < b.N; i++ {
-		parseFile("../1brc/measurements.txt", 1)
+		parseFile("../1brc/measurements.txt", 1, "mmap")
 	}
 }
 
 func BenchmarkTwoThreads(b *testing.B) {
 	for i := 0; i < b.N; i++ {
-		parseFile("../1brc/measurements.txt", 2)
+		parseFile("../1brc/measurements.txt", 2, "mmap")
 	}
 }
 
 func BenchmarkFourThreads(b *testing.B) {
 	for i := 0; i < b.N; i++ {
-		parseFile("../1brc/measurements.txt", 4)
+		parseFile("../1brc/measurements.txt", 4, "mmap")
 	}
 }
 
 func BenchmarkEightThreads(b *testing.B) {
 	for i := 0; i < b.N; i++ {
-		parseFile("../1brc/measurements.txt", 8)
+		parseFile("../1brc/measurements.txt", 8, "mmap")
 	}
 }
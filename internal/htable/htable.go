@@ -0,0 +1,189 @@
+//  Copyright 2024 Mikko Tuohimaa
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+
+// Package htable implements a fixed-capacity, open-addressed hash table for
+// aggregating per-station statistics by name.
+//
+// It exists because keying a Go map by a 32-bit hash (as a quick-and-dirty
+// alternative) silently merges any two station names that collide. This
+// table stores the full name alongside the hash and verifies it with
+// bytes.Equal before ever treating two entries as the same station, so
+// collisions only cost an extra probe instead of corrupting results.
+package htable
+
+import "bytes"
+
+// DefaultCapacity is the initial number of slots. It comfortably covers the
+// ~413 station names in the canonical 1BRC dataset with room to grow before
+// the load factor forces a resize.
+const DefaultCapacity = 16384
+
+// maxLoadFactor is the fraction of occupied slots beyond which the table
+// doubles its capacity and rehashes.
+const maxLoadFactor = 0.75
+
+// Stats holds the running aggregate for a single station name.
+type Stats struct {
+	Min, Max, Sum int64
+	Count         int64
+}
+
+type slot struct {
+	hash  uint64
+	name  []byte
+	stats Stats
+	used  bool
+}
+
+// Table is an open-addressed, linear-probed hash table keyed by station
+// name. The zero value is not usable; construct one with New.
+type Table struct {
+	slots []slot
+	mask  uint64
+	count int
+}
+
+// New returns an empty Table with DefaultCapacity slots.
+func New() *Table {
+	return newWithCapacity(DefaultCapacity)
+}
+
+func newWithCapacity(capacity int) *Table {
+	return &Table{
+		slots: make([]slot, capacity),
+		mask:  uint64(capacity) - 1,
+	}
+}
+
+// Hash computes a 64-bit FNV-1a hash of name.
+func Hash(name []byte) uint64 {
+	h := uint64(14695981039346656037)
+	for _, b := range name {
+		h ^= uint64(b)
+		h *= 1099511628211
+	}
+	return h
+}
+
+// Add inserts value into the running stats for name, creating the entry if
+// this is the first time name has been seen. name is copied if a new entry
+// is created, so the caller's slice may be reused afterwards.
+func (t *Table) Add(name []byte, value int64) {
+	t.add(Hash(name), name, value)
+}
+
+func (t *Table) add(hash uint64, name []byte, value int64) {
+	if float64(t.count+1) > maxLoadFactor*float64(len(t.slots)) {
+		t.grow()
+	}
+
+	i := t.find(hash, name)
+	s := &t.slots[i]
+	if !s.used {
+		s.used = true
+		s.hash = hash
+		s.name = append([]byte(nil), name...)
+		s.stats = Stats{Min: value, Max: value, Sum: value, Count: 1}
+		t.count++
+		return
+	}
+
+	if value < s.stats.Min {
+		s.stats.Min = value
+	}
+	if value > s.stats.Max {
+		s.stats.Max = value
+	}
+	s.stats.Sum += value
+	s.stats.Count++
+}
+
+// find returns the index of the slot holding (hash, name), or the first
+// empty slot on the probe sequence if no such entry exists.
+func (t *Table) find(hash uint64, name []byte) uint64 {
+	i := hash & t.mask
+	for {
+		s := &t.slots[i]
+		if !s.used || (s.hash == hash && bytes.Equal(s.name, name)) {
+			return i
+		}
+		i = (i + 1) & t.mask
+	}
+}
+
+func (t *Table) grow() {
+	grown := newWithCapacity(len(t.slots) * 2)
+	for _, s := range t.slots {
+		if !s.used {
+			continue
+		}
+		i := grown.find(s.hash, s.name)
+		grown.slots[i] = s
+	}
+	grown.count = t.count
+	*t = *grown
+}
+
+// Merge folds other's entries into t, combining stats for any station
+// present in both tables. other is left unmodified.
+func (t *Table) Merge(other *Table) {
+	for _, s := range other.slots {
+		if !s.used {
+			continue
+		}
+		t.mergeOne(s.hash, s.name, s.stats)
+	}
+}
+
+func (t *Table) mergeOne(hash uint64, name []byte, stats Stats) {
+	if float64(t.count+1) > maxLoadFactor*float64(len(t.slots)) {
+		t.grow()
+	}
+
+	i := t.find(hash, name)
+	s := &t.slots[i]
+	if !s.used {
+		s.used = true
+		s.hash = hash
+		s.name = append([]byte(nil), name...)
+		s.stats = stats
+		t.count++
+		return
+	}
+
+	if stats.Min < s.stats.Min {
+		s.stats.Min = stats.Min
+	}
+	if stats.Max > s.stats.Max {
+		s.stats.Max = stats.Max
+	}
+	s.stats.Sum += stats.Sum
+	s.stats.Count += stats.Count
+}
+
+// Len returns the number of distinct station names stored.
+func (t *Table) Len() int {
+	return t.count
+}
+
+// Each calls fn once per stored station, in unspecified order. fn must not
+// retain name past the call.
+func (t *Table) Each(fn func(name []byte, stats Stats)) {
+	for _, s := range t.slots {
+		if s.used {
+			fn(s.name, s.stats)
+		}
+	}
+}
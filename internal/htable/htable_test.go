@@ -0,0 +1,116 @@
+package htable
+
+import "testing"
+
+func TestAddAndMerge(t *testing.T) {
+	table := New()
+	table.Add([]byte("Tampere"), 210)
+	table.Add([]byte("Tampere"), -50)
+	table.Add([]byte("Helsinki"), 100)
+
+	other := New()
+	other.Add([]byte("Tampere"), 300)
+
+	table.Merge(other)
+
+	got := map[string]Stats{}
+	table.Each(func(name []byte, stats Stats) {
+		got[string(name)] = stats
+	})
+
+	tampere, ok := got["Tampere"]
+	if !ok {
+		t.Fatalf("Tampere missing from table")
+	}
+	if tampere.Min != -50 || tampere.Max != 300 || tampere.Sum != 460 || tampere.Count != 3 {
+		t.Errorf("Tampere = %+v, want {Min:-50 Max:300 Sum:460 Count:3}", tampere)
+	}
+
+	helsinki, ok := got["Helsinki"]
+	if !ok || helsinki.Count != 1 {
+		t.Errorf("Helsinki = %+v, want Count:1", helsinki)
+	}
+}
+
+func TestDistinguishesBucketCollisions(t *testing.T) {
+	// "Station588" and "Station694" are confirmed (by brute-force search)
+	// to land in the same DefaultCapacity-sized bucket: Hash(name)&mask is
+	// 914 for both, even though their full 64-bit hashes differ. This
+	// drives the second Add through find()'s probing loop instead of
+	// landing in an empty slot on the first try.
+	const nameA, nameB = "Station588", "Station694"
+	if bucket := Hash([]byte(nameA)) & (DefaultCapacity - 1); bucket != Hash([]byte(nameB))&(DefaultCapacity-1) {
+		t.Fatalf("test fixture assumption broken: %q and %q no longer collide at DefaultCapacity", nameA, nameB)
+	}
+
+	table := New()
+	table.Add([]byte(nameA), 10)
+	table.Add([]byte(nameB), 20)
+
+	if table.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2 (colliding names must not merge)", table.Len())
+	}
+
+	got := map[string]Stats{}
+	table.Each(func(name []byte, stats Stats) {
+		got[string(name)] = stats
+	})
+
+	if stats, ok := got[nameA]; !ok || stats.Sum != 10 || stats.Count != 1 {
+		t.Errorf("%s = %+v, want {Sum:10 Count:1}", nameA, stats)
+	}
+	if stats, ok := got[nameB]; !ok || stats.Sum != 20 || stats.Count != 1 {
+		t.Errorf("%s = %+v, want {Sum:20 Count:1}", nameB, stats)
+	}
+}
+
+func TestDistinguishesIdenticalHashDifferentName(t *testing.T) {
+	// Forces the case bucket-level collisions can't: two distinct names
+	// sharing the exact same 64-bit hash. Without the bytes.Equal check in
+	// find(), the second add would match the first entry's slot purely on
+	// hash equality and silently merge into its stats.
+	const fakeHash = uint64(0xDEADBEEF)
+
+	table := New()
+	table.add(fakeHash, []byte("Ea"), 10)
+	table.add(fakeHash, []byte("FB"), 20)
+
+	if table.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2 (identical-hash names must not merge)", table.Len())
+	}
+
+	got := map[string]Stats{}
+	table.Each(func(name []byte, stats Stats) {
+		got[string(name)] = stats
+	})
+
+	if stats, ok := got["Ea"]; !ok || stats.Sum != 10 || stats.Count != 1 {
+		t.Errorf("Ea = %+v, want {Sum:10 Count:1}", stats)
+	}
+	if stats, ok := got["FB"]; !ok || stats.Sum != 20 || stats.Count != 1 {
+		t.Errorf("FB = %+v, want {Sum:20 Count:1}", stats)
+	}
+}
+
+func TestGrowPreservesEntries(t *testing.T) {
+	table := newWithCapacity(4)
+	names := []string{"A", "B", "C", "D", "E", "F"}
+	for _, n := range names {
+		table.Add([]byte(n), 1)
+	}
+
+	if table.Len() != len(names) {
+		t.Fatalf("Len() = %d, want %d", table.Len(), len(names))
+	}
+	for _, n := range names {
+		found := false
+		table.Each(func(name []byte, _ Stats) {
+			if string(name) == n {
+				found = true
+			}
+		})
+		if !found {
+			t.Errorf("entry %q lost after grow", n)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+//  Copyright 2024 Mikko Tuohimaa
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+
+// Package mmapio memory-maps byte ranges of a file for allocation-free,
+// copy-free reading. It is an alternative to reading through a
+// bufio.Scanner, which has to copy every line into its internal buffer.
+package mmapio
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+)
+
+// DefaultWindowSize bounds how much of a file is mapped into memory at
+// once. Mapping a multi-gigabyte region in a single call works fine on
+// 64-bit systems, but bounding it keeps resident memory predictable when a
+// single worker is handed a very large slice of a huge file.
+const DefaultWindowSize = 1 << 30 // 1 GiB
+
+// Mapping is a memory-mapped view of part of a file. Call Close when done
+// with it to release the mapping.
+type Mapping struct {
+	data []byte
+	raw  rawMapping
+}
+
+// Bytes returns the mapped region.
+func (m *Mapping) Bytes() []byte {
+	return m.data
+}
+
+// Close unmaps the region.
+func (m *Mapping) Close() error {
+	return m.raw.close()
+}
+
+// Map maps the [offset, offset+length) region of file into memory.
+func Map(file *os.File, offset, length int64) (*Mapping, error) {
+	if length == 0 {
+		return &Mapping{}, nil
+	}
+	return mapRange(file, offset, length)
+}
+
+// ForEachWindow maps the [offset, offset+length) region of file in
+// successive windows of at most windowSize bytes (DefaultWindowSize if
+// windowSize <= 0), trimming each window back to its last newline so a
+// line is never split across two windows; the trimmed remainder is mapped
+// again as the start of the next window. fn is called once per window.
+func ForEachWindow(file *os.File, offset, length, windowSize int64, fn func(window []byte) error) error {
+	if windowSize <= 0 {
+		windowSize = DefaultWindowSize
+	}
+
+	for remaining := length; remaining > 0; {
+		mapLen := remaining
+		if mapLen > windowSize {
+			mapLen = windowSize
+		}
+
+		mapping, err := Map(file, offset, mapLen)
+		if err != nil {
+			return fmt.Errorf("mmapio: mapping offset %d length %d: %w", offset, mapLen, err)
+		}
+
+		window := mapping.Bytes()
+		if mapLen < remaining {
+			cut := bytes.LastIndexByte(window, '\n')
+			if cut < 0 {
+				mapping.Close()
+				return fmt.Errorf("mmapio: no newline in %d-byte window at offset %d; line exceeds windowSize", mapLen, offset)
+			}
+			window = window[:cut+1]
+		}
+
+		err = fn(window)
+		consumed := int64(len(window))
+		mapping.Close()
+		if err != nil {
+			return err
+		}
+		if consumed == 0 {
+			return fmt.Errorf("mmapio: window at offset %d has no newline to split on", offset)
+		}
+
+		offset += consumed
+		remaining -= consumed
+	}
+
+	return nil
+}
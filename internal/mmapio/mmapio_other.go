@@ -0,0 +1,30 @@
+//  Copyright 2024 Mikko Tuohimaa
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+//go:build !unix && !windows
+
+package mmapio
+
+import (
+	"errors"
+	"os"
+)
+
+type rawMapping struct{}
+
+func (rawMapping) close() error { return nil }
+
+func mapRange(file *os.File, offset, length int64) (*Mapping, error) {
+	return nil, errors.New("mmapio: mmap is not supported on this platform")
+}
@@ -0,0 +1,75 @@
+package mmapio
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func tempFileWithContent(t *testing.T, content string) *os.File {
+	t.Helper()
+	f, err := os.CreateTemp(t.TempDir(), "mmapio-test")
+	if err != nil {
+		t.Fatalf("CreateTemp: %v", err)
+	}
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatalf("WriteString: %v", err)
+	}
+	return f
+}
+
+func TestMapReturnsExactRange(t *testing.T) {
+	f := tempFileWithContent(t, "alpha;1.0\nbeta;2.0\ngamma;3.0\n")
+	defer f.Close()
+
+	m, err := Map(f, 10, 9)
+	if err != nil {
+		t.Fatalf("Map: %v", err)
+	}
+	defer m.Close()
+
+	if got := string(m.Bytes()); got != "beta;2.0\n" {
+		t.Errorf("Bytes() = %q, want %q", got, "beta;2.0\n")
+	}
+}
+
+func TestForEachWindowSplitsOnNewlines(t *testing.T) {
+	content := "alpha;1.0\nbeta;2.0\ngamma;3.0\n"
+	f := tempFileWithContent(t, content)
+	defer f.Close()
+
+	var windows []string
+	err := ForEachWindow(f, 0, int64(len(content)), 12, func(window []byte) error {
+		windows = append(windows, string(window))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEachWindow: %v", err)
+	}
+
+	var rebuilt string
+	for _, w := range windows {
+		rebuilt += w
+	}
+	if rebuilt != content {
+		t.Errorf("rebuilt windows = %q, want %q", rebuilt, content)
+	}
+	for _, w := range windows {
+		if len(w) > 0 && w[len(w)-1] != '\n' {
+			t.Errorf("window %q does not end on a line boundary", w)
+		}
+	}
+}
+
+func TestForEachWindowErrorsOnLineLongerThanWindow(t *testing.T) {
+	content := "alpha;1.0\n" + strings.Repeat("x", 20) + ";2.0\ngamma;3.0\n"
+	f := tempFileWithContent(t, content)
+	defer f.Close()
+
+	err := ForEachWindow(f, 0, int64(len(content)), 12, func(window []byte) error {
+		return nil
+	})
+	if err == nil {
+		t.Fatal("ForEachWindow: want error for a line longer than windowSize, got nil")
+	}
+}
@@ -0,0 +1,49 @@
+//  Copyright 2024 Mikko Tuohimaa
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+//go:build unix
+
+package mmapio
+
+import (
+	"os"
+	"syscall"
+)
+
+type rawMapping struct {
+	aligned []byte
+}
+
+func (r rawMapping) close() error {
+	if r.aligned == nil {
+		return nil
+	}
+	return syscall.Munmap(r.aligned)
+}
+
+func mapRange(file *os.File, offset, length int64) (*Mapping, error) {
+	pageSize := int64(syscall.Getpagesize())
+	alignedOffset := offset - offset%pageSize
+	pad := offset - alignedOffset
+
+	aligned, err := syscall.Mmap(int(file.Fd()), alignedOffset, int(pad+length), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Mapping{
+		data: aligned[pad : pad+length],
+		raw:  rawMapping{aligned: aligned},
+	}, nil
+}
@@ -0,0 +1,87 @@
+//  Copyright 2024 Mikko Tuohimaa
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+//go:build windows
+
+package mmapio
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	modkernel32            = syscall.NewLazyDLL("kernel32.dll")
+	procCreateFileMappingW = modkernel32.NewProc("CreateFileMappingW")
+	procMapViewOfFile      = modkernel32.NewProc("MapViewOfFile")
+	procUnmapViewOfFile    = modkernel32.NewProc("UnmapViewOfFile")
+	procCloseHandle        = modkernel32.NewProc("CloseHandle")
+)
+
+const (
+	pageReadonly = 0x02
+	fileMapRead  = 0x0004
+	// allocationGranularity is the boundary MapViewOfFile requires views to
+	// start on; it's coarser than the page size on Windows.
+	allocationGranularity = 1 << 16
+)
+
+type rawMapping struct {
+	handle syscall.Handle
+	addr   uintptr
+}
+
+func (r rawMapping) close() error {
+	if r.addr != 0 {
+		procUnmapViewOfFile.Call(r.addr)
+	}
+	if r.handle != 0 {
+		procCloseHandle.Call(uintptr(r.handle))
+	}
+	return nil
+}
+
+func mapRange(file *os.File, offset, length int64) (*Mapping, error) {
+	alignedOffset := offset - offset%allocationGranularity
+	pad := offset - alignedOffset
+	mapLength := pad + length
+	mapEnd := alignedOffset + mapLength
+
+	h, _, callErr := procCreateFileMappingW.Call(
+		file.Fd(), 0, pageReadonly,
+		uintptr(mapEnd>>32), uintptr(mapEnd&0xffffffff), 0,
+	)
+	if h == 0 {
+		return nil, fmt.Errorf("mmapio: CreateFileMappingW: %w", callErr)
+	}
+	handle := syscall.Handle(h)
+
+	addr, _, callErr := procMapViewOfFile.Call(
+		uintptr(handle), fileMapRead,
+		uintptr(alignedOffset>>32), uintptr(alignedOffset&0xffffffff), uintptr(mapLength),
+	)
+	if addr == 0 {
+		procCloseHandle.Call(uintptr(handle))
+		return nil, fmt.Errorf("mmapio: MapViewOfFile: %w", callErr)
+	}
+
+	full := unsafe.Slice((*byte)(unsafe.Pointer(addr)), mapLength)
+
+	return &Mapping{
+		data: full[pad : pad+length],
+		raw:  rawMapping{handle: handle, addr: addr},
+	}, nil
+}
@@ -0,0 +1,185 @@
+//  Copyright 2024 Mikko Tuohimaa
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+//
+
+// Package output renders aggregated station statistics in formats other
+// than the 1BRC challenge's reference text output, so results can be piped
+// into downstream tooling instead of just printed to a terminal.
+package output
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Station is one row of aggregated statistics, ready to be rendered by a
+// Formatter.
+type Station struct {
+	Name  string
+	Min   float64
+	Mean  float64
+	Max   float64
+	Sum   float64
+	Count int64
+}
+
+// Formatter renders stations, in the order given, to w.
+type Formatter interface {
+	Format(w io.Writer, stations []Station) error
+}
+
+// ByName returns the Formatter registered under name.
+func ByName(name string) (Formatter, error) {
+	switch name {
+	case "text":
+		return TextFormatter{}, nil
+	case "ndjson":
+		return NDJSONFormatter{}, nil
+	case "csv":
+		return CSVFormatter{}, nil
+	case "columnar":
+		return ColumnarFormatter{}, nil
+	default:
+		return nil, fmt.Errorf("output: unknown format %q (want text, ndjson, csv, or columnar)", name)
+	}
+}
+
+// TextFormatter reproduces the 1BRC challenge's reference output:
+// "Total locations: N\n{name=min/mean/max, ...}\n".
+type TextFormatter struct{}
+
+func (TextFormatter) Format(w io.Writer, stations []Station) error {
+	fmt.Fprintln(w, "Total locations:", len(stations))
+
+	parts := make([]string, len(stations))
+	for i, s := range stations {
+		parts[i] = fmt.Sprintf("%s=%.1f/%.1f/%.1f", s.Name, s.Min, s.Mean, s.Max)
+	}
+	_, err := fmt.Fprintf(w, "{%s}\n", strings.Join(parts, ", "))
+	return err
+}
+
+// NDJSONFormatter writes one JSON object per station, one per line.
+type NDJSONFormatter struct{}
+
+func (NDJSONFormatter) Format(w io.Writer, stations []Station) error {
+	enc := json.NewEncoder(w)
+	for _, s := range stations {
+		record := struct {
+			Station string  `json:"station"`
+			Min     float64 `json:"min"`
+			Mean    float64 `json:"mean"`
+			Max     float64 `json:"max"`
+			Count   int64   `json:"count"`
+		}{s.Name, s.Min, s.Mean, s.Max, s.Count}
+
+		if err := enc.Encode(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CSVFormatter writes an RFC 4180 CSV with a header row.
+type CSVFormatter struct{}
+
+func (CSVFormatter) Format(w io.Writer, stations []Station) error {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write([]string{"station", "min", "mean", "max", "count"}); err != nil {
+		return err
+	}
+	for _, s := range stations {
+		record := []string{
+			s.Name,
+			strconv.FormatFloat(s.Min, 'f', 1, 64),
+			strconv.FormatFloat(s.Mean, 'f', 1, 64),
+			strconv.FormatFloat(s.Max, 'f', 1, 64),
+			strconv.FormatInt(s.Count, 10),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// columnarMagic identifies a ColumnarFormatter dump.
+var columnarMagic = [4]byte{'1', 'B', 'R', 'C'}
+
+// ColumnarFormatter writes a compact binary dump suitable for loading
+// straight into pandas or DuckDB: a small fixed header followed by the
+// names and then the min/max/sum/count columns as flat parallel arrays, so
+// a reader can load each column without parsing text.
+//
+// Layout (all multi-byte integers little-endian):
+//
+//	magic   [4]byte  "1BRC"
+//	count   uint32   number of stations
+//	names   count × (uint16 length, UTF-8 bytes)
+//	mins    count × float64
+//	maxes   count × float64
+//	sums    count × float64
+//	counts  count × int64
+type ColumnarFormatter struct{}
+
+func (ColumnarFormatter) Format(w io.Writer, stations []Station) error {
+	bw := bufio.NewWriter(w)
+
+	if _, err := bw.Write(columnarMagic[:]); err != nil {
+		return err
+	}
+	if err := binary.Write(bw, binary.LittleEndian, uint32(len(stations))); err != nil {
+		return err
+	}
+
+	for _, s := range stations {
+		name := []byte(s.Name)
+		if err := binary.Write(bw, binary.LittleEndian, uint16(len(name))); err != nil {
+			return err
+		}
+		if _, err := bw.Write(name); err != nil {
+			return err
+		}
+	}
+
+	columns := []func(Station) float64{
+		func(s Station) float64 { return s.Min },
+		func(s Station) float64 { return s.Max },
+		func(s Station) float64 { return s.Sum },
+	}
+	for _, column := range columns {
+		for _, s := range stations {
+			if err := binary.Write(bw, binary.LittleEndian, column(s)); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, s := range stations {
+		if err := binary.Write(bw, binary.LittleEndian, s.Count); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
@@ -0,0 +1,110 @@
+package output
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+)
+
+var sampleStations = []Station{
+	{Name: "Helsinki", Min: -5.3, Mean: -5.3, Max: -5.3, Sum: -5.3, Count: 1},
+	{Name: "Tampere", Min: 21.0, Mean: 25.5, Max: 30.0, Sum: 51.0, Count: 2},
+}
+
+func TestTextFormatter(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (TextFormatter{}).Format(&buf, sampleStations); err != nil {
+		t.Fatalf("Format: %v", err)
+	}
+
+	want := "Total locations: 2\n{Helsinki=-5.3/-5.3/-5.3, Tampere=21.0/25.5/30.0}\n"
+	if buf.String() != want {
+		t.Errorf("Format() = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestNDJSONFormatter(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (NDJSONFormatter{}).Format(&buf, sampleStations); err != nil {
+		t.Fatalf("Format: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != len(sampleStations) {
+		t.Fatalf("got %d lines, want %d", len(lines), len(sampleStations))
+	}
+	if !strings.Contains(lines[0], `"station":"Helsinki"`) {
+		t.Errorf("line 0 = %q, want it to mention Helsinki", lines[0])
+	}
+}
+
+func TestCSVFormatter(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (CSVFormatter{}).Format(&buf, sampleStations); err != nil {
+		t.Fatalf("Format: %v", err)
+	}
+
+	want := "station,min,mean,max,count\nHelsinki,-5.3,-5.3,-5.3,1\nTampere,21.0,25.5,30.0,2\n"
+	if buf.String() != want {
+		t.Errorf("Format() = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestColumnarFormatterRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (ColumnarFormatter{}).Format(&buf, sampleStations); err != nil {
+		t.Fatalf("Format: %v", err)
+	}
+
+	data := buf.Bytes()
+	if string(data[:4]) != "1BRC" {
+		t.Fatalf("magic = %q, want %q", data[:4], "1BRC")
+	}
+
+	count := binary.LittleEndian.Uint32(data[4:8])
+	if int(count) != len(sampleStations) {
+		t.Fatalf("count = %d, want %d", count, len(sampleStations))
+	}
+
+	offset := 8
+	names := make([]string, count)
+	for i := range names {
+		nameLen := binary.LittleEndian.Uint16(data[offset : offset+2])
+		offset += 2
+		names[i] = string(data[offset : offset+int(nameLen)])
+		offset += int(nameLen)
+	}
+	if names[0] != "Helsinki" || names[1] != "Tampere" {
+		t.Errorf("names = %v, want [Helsinki Tampere]", names)
+	}
+
+	readFloat64 := func() float64 {
+		bits := binary.LittleEndian.Uint64(data[offset : offset+8])
+		offset += 8
+		return math.Float64frombits(bits)
+	}
+
+	mins := []float64{readFloat64(), readFloat64()}
+	maxes := []float64{readFloat64(), readFloat64()}
+	sums := []float64{readFloat64(), readFloat64()}
+
+	if mins[0] != -5.3 || mins[1] != 21.0 {
+		t.Errorf("mins = %v, want [-5.3 21.0]", mins)
+	}
+	if maxes[0] != -5.3 || maxes[1] != 30.0 {
+		t.Errorf("maxes = %v, want [-5.3 30.0]", maxes)
+	}
+	if sums[0] != -5.3 || sums[1] != 51.0 {
+		t.Errorf("sums = %v, want [-5.3 51.0]", sums)
+	}
+
+	counts := []int64{
+		int64(binary.LittleEndian.Uint64(data[offset : offset+8])),
+		int64(binary.LittleEndian.Uint64(data[offset+8 : offset+16])),
+	}
+	if counts[0] != 1 || counts[1] != 2 {
+		t.Errorf("counts = %v, want [1 2]", counts)
+	}
+}